@@ -0,0 +1,62 @@
+package govec
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+//logViaHelper calls LogLocalEventWithPriority and reports the exact
+//"file:line" GoLog will record as its caller, so a test can configure
+//BacktraceAt to match without hardcoding a line number.
+func logViaHelper(gv *GoLog, msg string, pri LogPriority) (callSite string, logSuccess bool) {
+	_, file, line, _ := runtime.Caller(0)
+	logSuccess = gv.LogLocalEventWithPriority(msg, pri)
+	callSite = file + ":" + strconv.Itoa(line+1)
+	return
+}
+
+//TestBacktraceOnlyWhenPriorityPasses verifies that a record filtered out
+//by priority never carries a Stack, even when its call site matches
+//BacktraceAt: callerLocation/captureStack only run once the priority
+//gate has already decided the record will be logged (see
+//LogLocalEventWithPriority), so a filtered call incurs neither the
+//stack capture nor its cost.
+func TestBacktraceOnlyWhenPriorityPasses(t *testing.T) {
+	probeConfig := GetDefaultConfig()
+	probeConfig.LogToFile = false
+	probe := InitGoVector("probe-pid", "unused", probeConfig)
+	callSite, _ := logViaHelper(probe, "probe", FATAL)
+	if callSite == "" {
+		t.Fatal("failed to determine call site")
+	}
+
+	var buf bytes.Buffer
+	sink := NewWriterSink(&buf, false, FormatJSON)
+
+	config := GetDefaultConfig()
+	config.LogToFile = false
+	config.Format = FormatJSON
+	config.Sinks = []LogSink{sink}
+	config.Priority = WARNING
+	config.BacktraceAt = []string{callSite}
+
+	gv := InitGoVector("test-pid", "unused", config)
+	buf.Reset()
+
+	if _, ok := logViaHelper(gv, "filtered out", DEBUG); !ok {
+		t.Fatal("LogLocalEventWithPriority reported failure")
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("expected no record for a filtered-out priority, got %q", buf.String())
+	}
+
+	if _, ok := logViaHelper(gv, "logged", WARNING); !ok {
+		t.Fatal("LogLocalEventWithPriority reported failure")
+	}
+	if !strings.Contains(buf.String(), `"stack"`) {
+		t.Fatalf("expected a captured stack on a logged record at the configured call site, got %q", buf.String())
+	}
+}
@@ -0,0 +1,124 @@
+package govec
+
+import "context"
+
+//vclockContextKey is the typed key PrepareSendContext,
+//UnpackReceiveContext, and govec/transport store a vector clock
+//snapshot under, so it can't collide with a caller's own context values.
+type vclockContextKey struct{}
+
+//ContextWithVClock returns a copy of ctx carrying vc, retrievable with
+//VClockFromContext.
+func ContextWithVClock(ctx context.Context, vc map[string]uint64) context.Context {
+	return context.WithValue(ctx, vclockContextKey{}, vc)
+}
+
+//VClockFromContext returns the vector clock snapshot carried by ctx, if
+//any, and whether one was found.
+func VClockFromContext(ctx context.Context) (map[string]uint64, bool) {
+	vc, ok := ctx.Value(vclockContextKey{}).(map[string]uint64)
+	return vc, ok
+}
+
+//PrepareSendContext is PrepareSend plus propagation: the returned
+//context carries a copy of the vector clock in effect right after
+//sending, under a typed key retrievable with VClockFromContext, so a
+//downstream handler can inspect or forward the same send's clock
+//without re-locking gv.mutex itself. The snapshot is taken in the same
+//critical section as the tick, like PrepareSendClock, rather than via a
+//separate lock afterwards, so it can't be raced by another goroutine's
+//tick or merge.
+func (gv *GoLog) PrepareSendContext(ctx context.Context, mesg string, buf interface{}) ([]byte, context.Context) {
+	caller := callerLocation(1)
+
+	gv.mutex.Lock()
+	defer gv.mutex.Unlock()
+
+	var stack string
+	if gv.shouldBacktrace(caller) {
+		stack = captureStack()
+	}
+
+	gv.tickClock()
+	gv.logWriteWrapperWithEvent(mesg, "Something went wrong, could not log prepare send", gv.priority, "send", "", nil, caller, stack)
+
+	d := ClockPayload{Pid: gv.pid, VcMap: gv.currentVC.GetMap(), Payload: buf}
+	encodedBytes, err := gv.encodingStrategy(&d)
+	if err != nil {
+		gv.logger.Println(err.Error())
+	}
+
+	vc := copyVcMap(gv.currentVC.GetMap())
+	return encodedBytes, ContextWithVClock(ctx, vc)
+}
+
+//UnpackReceiveContext is UnpackReceive plus propagation: the returned
+//context carries a copy of the vector clock in effect right after the
+//merge, under a typed key retrievable with VClockFromContext. The
+//snapshot is taken in the same critical section as the merge, like
+//MergeReceivedClock, rather than via a separate lock afterwards, so it
+//can't be raced by another goroutine's tick or merge.
+func (gv *GoLog) UnpackReceiveContext(ctx context.Context, mesg string, buf []byte, unpack interface{}) context.Context {
+	caller := callerLocation(1)
+
+	gv.mutex.Lock()
+	defer gv.mutex.Unlock()
+
+	var stack string
+	if gv.shouldBacktrace(caller) {
+		stack = captureStack()
+	}
+
+	e := ClockPayload{Payload: unpack}
+	if err := gv.decodingStrategy(buf, &e); err != nil {
+		gv.logger.Println(err.Error())
+	}
+	gv.mergeIncomingClock(mesg, e, gv.priority, caller, stack)
+
+	vc := copyVcMap(gv.currentVC.GetMap())
+	return ContextWithVClock(ctx, vc)
+}
+
+//PrepareSendClock ticks the local clock, logs mesg as a "send" event,
+//and returns a copy of the resulting vector clock as a map. Unlike
+//PrepareSend, it doesn't wrap a payload in a ClockPayload; it's for
+//transports (like govec/transport) that carry the clock in a header or
+//other out-of-band field alongside the caller's own wire format.
+func (gv *GoLog) PrepareSendClock(mesg string) map[string]uint64 {
+	caller := callerLocation(1)
+
+	gv.mutex.Lock()
+	defer gv.mutex.Unlock()
+
+	var stack string
+	if gv.shouldBacktrace(caller) {
+		stack = captureStack()
+	}
+
+	gv.tickClock()
+	gv.logWriteWrapperWithEvent(mesg, "Something went wrong, could not log prepare send", gv.priority, "send", "", nil, caller, stack)
+	return copyVcMap(gv.currentVC.GetMap())
+}
+
+//MergeReceivedClock merges peerVc, a vector clock decoded off the wire
+//from peerPid, into the local clock, logs mesg as a "recv" event, and
+//returns a copy of the resulting vector clock. Like PrepareSendClock,
+//it's for transports that carry the clock out-of-band rather than
+//through UnpackReceive's ClockPayload envelope.
+func (gv *GoLog) MergeReceivedClock(mesg string, peerPid string, peerVc map[string]uint64) map[string]uint64 {
+	caller := callerLocation(1)
+
+	gv.mutex.Lock()
+	defer gv.mutex.Unlock()
+
+	var stack string
+	if gv.shouldBacktrace(caller) {
+		stack = captureStack()
+	}
+
+	snapshot := copyVcMap(peerVc)
+	gv.tickClock()
+	gv.currentVC.Merge(peerVc)
+	gv.logWriteWrapperWithEvent(mesg, "Something went Wrong, Could not Log!", gv.priority, "recv", peerPid, snapshot, caller, stack)
+	return copyVcMap(gv.currentVC.GetMap())
+}
@@ -0,0 +1,55 @@
+package govec
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+//TestPrepareSendContextSnapshotIsAtomic exercises many goroutines calling
+//PrepareSendContext concurrently on a shared GoLog. Each call should see
+//a vector clock snapshot for exactly the tick it caused: if the snapshot
+//were taken outside the critical section that does the tick (as it used
+//to be), a concurrent goroutine's tick could land in the gap and two
+//calls could observe the same count, or a count could be skipped
+//entirely. Run with -race to also catch the unsynchronized access this
+//used to allow.
+func TestPrepareSendContextSnapshotIsAtomic(t *testing.T) {
+	gv := InitGoVector("test-pid", "unused", GoLogConfig{Priority: INFO})
+
+	const n = 200
+	counts := make([]uint64, n)
+
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			defer wg.Done()
+			_, ctx := gv.PrepareSendContext(context.Background(), "send", i)
+			vc, ok := VClockFromContext(ctx)
+			if !ok {
+				t.Errorf("context %d missing vector clock", i)
+				return
+			}
+			counts[i] = vc["test-pid"]
+		}(i)
+	}
+	wg.Wait()
+
+	seen := make(map[uint64]bool, n)
+	lowest := counts[0]
+	for _, c := range counts {
+		if seen[c] {
+			t.Fatalf("tick %d observed more than once across concurrent PrepareSendContext calls", c)
+		}
+		seen[c] = true
+		if c < lowest {
+			lowest = c
+		}
+	}
+	for want := lowest; want < lowest+n; want++ {
+		if !seen[want] {
+			t.Fatalf("tick %d never observed by any PrepareSendContext call", want)
+		}
+	}
+}
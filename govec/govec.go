@@ -8,7 +8,7 @@ import (
 	"github.com/vmihailenco/msgpack"
 	"log"
 	"os"
-	"path/filepath"
+	"runtime"
 	"strconv"
 	"sync"
 	"time"
@@ -93,6 +93,26 @@ func (l LogPriority) getPrefixString() string {
 	return prefix
 }
 
+//name returns l's canonical enum name (DEBUG, INFO, ...), used for the
+//"priority" field of a FormatJSON record. Unlike getPrefixString, this
+//doesn't carry FormatText's legacy "NORMAL" spelling for INFO.
+func (l LogPriority) name() string {
+	switch l {
+	case DEBUG:
+		return "DEBUG"
+	case INFO:
+		return "INFO"
+	case WARNING:
+		return "WARNING"
+	case ERROR:
+		return "ERROR"
+	case FATAL:
+		return "FATAL"
+	default:
+		return ""
+	}
+}
+
 type GoLogConfig struct {
 	Buffered         bool
 	PrintOnScreen    bool
@@ -102,11 +122,57 @@ type GoLogConfig struct {
 	DecodingStrategy func([]byte, interface{}) error
 	LogToFile        bool
 	Priority         LogPriority
+
+	//Format selects how the default FileSink renders records: FormatText
+	//(the default) or FormatJSON. See LogFormat. Sinks passed in Sinks
+	//keep the format given to NewWriterSink/NewFileSink when they were
+	//created.
+	Format LogFormat
+
+	//Additional LogSinks to dispatch records to, alongside the default
+	//file sink (when LogToFile is set). See LogSink for built-in sinks
+	//covering io.Writer, syslog, and network collectors.
+	Sinks []LogSink
+
+	//Default threshold used by V when no VModule pattern matches the
+	//caller's file. Leave at 0 to only enable V(0) call sites.
+	Verbosity int32
+
+	//Comma-separated "pattern=level" overrides for V, e.g.
+	//"raft.go=3,paxos/*=2". See GoLog.SetVModule for the pattern syntax.
+	VModule string
+
+	//Rotate the log file once it reaches this size in bytes. Zero
+	//disables size-based rotation.
+	MaxSizeBytes int64
+
+	//Rotate the log file once it has been open this many seconds. Zero
+	//disables age-based rotation.
+	MaxAgeSeconds int64
+
+	//Number of rotated log segments to retain; the oldest are deleted
+	//first. Zero keeps every segment.
+	MaxBackups int
+
+	//Gzip a log segment immediately after it is rotated out.
+	Compress bool
+
+	//BacktraceAt lists "file:line" call sites, as reported by
+	//LogLocalEventWithPriority, PrepareSendWithPriority, or
+	//UnpackReceiveWithPriority's own caller, that get a full goroutine
+	//stack captured and attached to their record. Modeled on glog's
+	//-log_backtrace_at.
+	BacktraceAt []string
+
+	//FatalExits, when true, makes logging a FATAL-priority event flush
+	//every sink, emit a final record with Event "fatal" and a stack
+	//trace, and call os.Exit(255).
+	FatalExits bool
 }
 
 //Returns the default GoLogConfig with default values for various fields.
 func GetDefaultConfig() GoLogConfig {
-	config := GoLogConfig{Buffered: false, PrintOnScreen: false, AppendLog: false, UseTimestamps: false, LogToFile: true, Priority: INFO}
+	config := GoLogConfig{Buffered: false, PrintOnScreen: false, AppendLog: false, UseTimestamps: false, LogToFile: true, Priority: INFO, Format: FormatText}
 	return config
 }
 
@@ -237,17 +303,39 @@ type GoLog struct {
 	//Flag to include timestamps when logging events
 	usetimestamps bool
 
+	//Record format written by the default FileSink; see LogFormat.
+	format LogFormat
+
+	//backtraceAt is the set of "file:line" call sites configured via
+	//GoLogConfig.BacktraceAt that trigger a captured stack trace.
+	backtraceAt map[string]bool
+
+	//fatalExits mirrors GoLogConfig.FatalExits.
+	fatalExits bool
+
 	//Flag to indicate if the log file will contain multiple executions
 	appendLog bool
 
 	//Priority level at which all events are logged
 	priority LogPriority
 
-	//Logfile name
-	logfile string
+	//Sinks that every log record is dispatched to. By default this
+	//holds a single FileSink (when LogToFile is set); config.Sinks are
+	//appended to it.
+	sinks []LogSink
+
+	//Default verbosity threshold for V, and the per-file/module
+	//overrides and per-call-site cache backing it. See verbosity.go.
+	verbosity    int32
+	vmoduleMutex sync.RWMutex
+	vmoduleRules []vmoduleRule
+	vmoduleCache sync.Map
 
-	//buffered string
-	output string
+	//Background ticker that flushes (and so rotates) sinks on a timer
+	//when Buffered is true; nil unless rotation is configured. See
+	//rotation.go.
+	rotationTicker *time.Ticker
+	rotationDone   chan struct{}
 
 	encodingStrategy func(interface{}) ([]byte, error)
 	decodingStrategy func([]byte, interface{}) error
@@ -275,11 +363,24 @@ func InitGoVector(processid string, logfilename string, config GoLogConfig) *GoL
 
 	gv.printonscreen = config.PrintOnScreen
 	gv.usetimestamps = config.UseTimestamps
+	gv.format = config.Format
 	gv.priority = config.Priority
+	gv.fatalExits = config.FatalExits
+	if len(config.BacktraceAt) > 0 {
+		gv.backtraceAt = make(map[string]bool, len(config.BacktraceAt))
+		for _, loc := range config.BacktraceAt {
+			gv.backtraceAt[loc] = true
+		}
+	}
 	gv.logging = config.LogToFile
 	gv.buffered = config.Buffered
 	gv.appendLog = config.AppendLog
-	gv.output = ""
+	gv.verbosity = config.Verbosity
+	if config.VModule != "" {
+		if err := gv.SetVModule(config.VModule); err != nil {
+			gv.logger.Println(err)
+		}
+	}
 
 	// Use the default encoder/decoder. As of July 2017 this is msgPack.
 	if config.EncodingStrategy == nil || config.DecodingStrategy == nil {
@@ -296,50 +397,39 @@ func InitGoVector(processid string, logfilename string, config GoLogConfig) *GoL
 
 	//Starting File IO . If Log exists, Log Will be deleted and A New one will be created
 	logname := logfilename + "-Log.txt"
-	gv.logfile = logname
-	gv.prepareLogFile()
-
-	return gv
-}
+	gv.attachSinks(logname, config)
 
-func (gv *GoLog) prepareLogFile() {
-	_, err := os.Stat(gv.logfile)
-	if err == nil {
-		if !gv.appendLog {
-			gv.logger.Println(gv.logfile, "exists! ... Deleting ")
-			os.Remove(gv.logfile)
-		} else {
-			executionnumber := time.Now().Format(time.UnixDate)
-			gv.logger.Println("Execution Number is  ", executionnumber)
-			executionstring := "=== Execution #" + executionnumber + "  ==="
-			gv.logThis(executionstring, "", "", gv.priority)
-			return
-		}
-	}
-	// Create directory path to log if it doesn't exist.
-	if err := os.MkdirAll(filepath.Dir(gv.logfile), 0750); err != nil {
-		gv.logger.Println(err)
+	ok := gv.logThis("Initialization Complete", gv.pid, gv.currentVC.ReturnVCString(), gv.priority)
+	if ok == false {
+		gv.logger.Println("Something went Wrong, Could not Log!")
 	}
 
-	//Creating new Log
-	file, err := os.Create(gv.logfile)
-	if err != nil {
-		gv.logger.Println(err)
+	if gv.buffered && (config.MaxSizeBytes > 0 || config.MaxAgeSeconds > 0) {
+		gv.startRotationTicker(time.Second)
 	}
 
-	file.Close()
-
-	if gv.appendLog {
-		executionnumber := time.Now().Format(time.UnixDate)
-		gv.logger.Println("Execution Number is  ", executionnumber)
-		executionstring := "=== Execution #" + executionnumber + "  ==="
-		gv.logThis(executionstring, "", "", gv.priority)
-	}
+	return gv
+}
 
-	ok := gv.logThis("Initialization Complete", gv.pid, gv.currentVC.ReturnVCString(), gv.priority)
-	if ok == false {
-		gv.logger.Println("Something went Wrong, Could not Log!")
+//attachSinks builds the default FileSink (when logging to file is
+//enabled) and appends any caller-supplied sinks from config.Sinks. Every
+//subsequent call to logThis dispatches to all of them.
+func (gv *GoLog) attachSinks(logname string, config GoLogConfig) {
+	if gv.logging {
+		rotation := RotationConfig{
+			MaxSizeBytes:  config.MaxSizeBytes,
+			MaxAgeSeconds: config.MaxAgeSeconds,
+			MaxBackups:    config.MaxBackups,
+			Compress:      config.Compress,
+		}
+		fileSink, err := NewFileSink(logname, gv.appendLog, gv.usetimestamps, gv.format, rotation)
+		if err != nil {
+			gv.logger.Println(err)
+		} else {
+			gv.sinks = append(gv.sinks, fileSink)
+		}
 	}
+	gv.sinks = append(gv.sinks, config.Sinks...)
 }
 
 //Returns the current vector clock
@@ -375,28 +465,22 @@ func (gv *GoLog) EnableBufferedWrites() {
 //log messages that haven't been written to Log file yet.
 func (gv *GoLog) DisableBufferedWrites() {
 	gv.buffered = false
-	if gv.output != "" {
-		gv.Flush()
-	}
+	gv.Flush()
 }
 
-//Writes the log messages stored in the buffer to the Log File. This
-//function should be used by the application to also force writes in
-//the case of interrupts and crashes.   Note: Calling Flush when
-//BufferedWrites is disabled is essentially a no-op.
+//Writes the log messages buffered by every attached sink out to their
+//destination (file, socket, syslog, ...). This function should be used
+//by the application to also force writes in the case of interrupts and
+//crashes. Note: Calling Flush when BufferedWrites is disabled is
+//essentially a no-op, since sinks are flushed on every log call already.
 func (gv *GoLog) Flush() bool {
 	complete := true
-	file, err := os.OpenFile(gv.logfile, os.O_APPEND|os.O_WRONLY, 0600)
-	if err != nil {
-		complete = false
-	}
-	defer file.Close()
-
-	if _, err = file.WriteString(gv.output); err != nil {
-		complete = false
+	for _, sink := range gv.sinks {
+		if err := sink.Flush(); err != nil {
+			gv.logger.Println(err)
+			complete = false
+		}
 	}
-
-	gv.output = ""
 	return complete
 }
 
@@ -409,40 +493,117 @@ func (gv *GoLog) printColoredMessage(LogMessage string, Priority LogPriority) {
 	fmt.Println(LogMessage)
 }
 
+//callerLocation returns "file:line" for the call site skip frames above
+//its own caller, e.g. callerLocation(1) names whoever called the
+//function that called callerLocation. It returns "" if the frame can't
+//be resolved.
+func callerLocation(skip int) string {
+	_, file, line, ok := runtime.Caller(skip + 1)
+	if !ok {
+		return ""
+	}
+	return file + ":" + strconv.Itoa(line)
+}
+
+//captureStack returns a dump of every goroutine's stack, the same
+//format a Go panic prints.
+func captureStack() string {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return string(buf[:n])
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+//shouldBacktrace reports whether caller matches a "file:line" location
+//configured via GoLogConfig.BacktraceAt.
+func (gv *GoLog) shouldBacktrace(caller string) bool {
+	return caller != "" && gv.backtraceAt[caller]
+}
+
 //Logs a message along with a processID and a vector clock, the VCString
 //must be a valid vector clock, true is returned on success
 func (gv *GoLog) logThis(Message string, ProcessID string, VCString string, Priority LogPriority) bool {
-	var (
-		complete = true
-		buffer   bytes.Buffer
-	)
-	if gv.usetimestamps {
-		buffer.WriteString(strconv.FormatInt(time.Now().UnixNano(), 10))
-		buffer.WriteString(" ")
-	}
-	buffer.WriteString(ProcessID)
-	buffer.WriteString(" ")
-	buffer.WriteString(VCString)
-	buffer.WriteString("\n")
-	buffer.WriteString(Message)
-	buffer.WriteString("\n")
-	output := buffer.String()
-
-	gv.output += output
+	return gv.logEvent(Message, ProcessID, VCString, Priority, "", "", nil, "", "")
+}
+
+//logEvent is logThis plus the event-kind/peer/caller/stack metadata
+//needed to populate a FormatJSON record; FormatText sinks ignore the
+//event/peer/caller metadata but do render stack, if present.
+func (gv *GoLog) logEvent(Message string, ProcessID string, VCString string, Priority LogPriority, event string, peer string, peerVc map[string]uint64, caller string, stack string) bool {
+	complete := true
+
+	record := LogRecord{
+		Pid:       ProcessID,
+		VCString:  VCString,
+		Message:   Message,
+		Priority:  Priority,
+		Timestamp: time.Now(),
+		VcMap:     copyVcMap(gv.currentVC.GetMap()),
+		Event:     event,
+		Peer:      peer,
+		PeerVc:    peerVc,
+		Caller:    caller,
+		Stack:     stack,
+	}
+
+	for _, sink := range gv.sinks {
+		if err := sink.Emit(record); err != nil {
+			gv.logger.Println(err)
+			complete = false
+		}
+	}
+
 	if !gv.buffered {
-		complete = gv.Flush()
+		if !gv.Flush() {
+			complete = false
+		}
 	}
 
 	if gv.printonscreen == true {
 		gv.printColoredMessage(Message, Priority)
 	}
+
+	if Priority == FATAL && gv.fatalExits {
+		gv.emitFatalAndExit()
+	}
+
 	return complete
 
 }
 
-func (gv *GoLog) logWriteWrapper(logMessage, errorMessage string, Priority LogPriority) (success bool) {
-	if gv.logging == true {
-		success = gv.logThis(logMessage, gv.pid, gv.currentVC.ReturnVCString(), Priority)
+//emitFatalAndExit flushes every sink, emits a final Event:"fatal" record
+//carrying a fresh stack trace, and terminates the process. logEvent
+//calls it whenever it has just logged a FATAL record and
+//GoLogConfig.FatalExits is set.
+func (gv *GoLog) emitFatalAndExit() {
+	final := LogRecord{
+		Pid:       gv.pid,
+		VCString:  gv.currentVC.ReturnVCString(),
+		Message:   "fatal: exiting",
+		Priority:  FATAL,
+		Timestamp: time.Now(),
+		VcMap:     copyVcMap(gv.currentVC.GetMap()),
+		Event:     "fatal",
+		Stack:     captureStack(),
+	}
+	for _, sink := range gv.sinks {
+		sink.Emit(final)
+	}
+	gv.Flush()
+	os.Exit(255)
+}
+
+func (gv *GoLog) logWriteWrapperWithEvent(logMessage, errorMessage string, Priority LogPriority, event string, peer string, peerVc map[string]uint64, caller string, stack string) (success bool) {
+	// Gate on whether there's anything to write to, not on LogToFile:
+	// gv.logging only reflects whether the default FileSink was
+	// created, but GoLogConfig.Sinks (syslog, network, ...) can give a
+	// GoLog somewhere to log even with LogToFile false.
+	if len(gv.sinks) > 0 {
+		success = gv.logEvent(logMessage, gv.pid, gv.currentVC.ReturnVCString(), Priority, event, peer, peerVc, caller, stack)
 		if !success {
 			gv.logger.Println(errorMessage)
 		}
@@ -474,9 +635,18 @@ func (gv *GoLog) LogLocalEventWithPriority(LogMessage string, Priority LogPriori
 	logSuccess = true
 	gv.mutex.Lock()
 	if Priority >= gv.priority {
+		// callerLocation/captureStack are only worth paying for once we
+		// know this record will actually be logged; captureStack in
+		// particular dumps every goroutine's stack.
+		caller := callerLocation(1)
+		var stack string
+		if gv.shouldBacktrace(caller) {
+			stack = captureStack()
+		}
+
 		prefix := Priority.getPrefixString() + " - "
 		gv.tickClock()
-		logSuccess = gv.logWriteWrapper(prefix+LogMessage, "Something went Wrong, Could not Log LocalEvent!", Priority)
+		logSuccess = gv.logWriteWrapperWithEvent(prefix+LogMessage, "Something went Wrong, Could not Log LocalEvent!", Priority, "local", "", nil, caller, stack)
 	}
 	gv.mutex.Unlock()
 	return
@@ -494,13 +664,22 @@ the clock using gob support and return the new byte array that should
 be sent onwards using the Send Command
 */
 func (gv *GoLog) PrepareSendWithPriority(mesg string, buf interface{}, Priority LogPriority) (encodedBytes []byte) {
-
 	//Converting Vector Clock from Bytes and Updating the gv clock
 	gv.mutex.Lock()
+	defer gv.mutex.Unlock()
 	if Priority >= gv.priority {
+		// callerLocation/captureStack are only worth paying for once we
+		// know this record will actually be logged; captureStack in
+		// particular dumps every goroutine's stack.
+		caller := callerLocation(1)
+		var stack string
+		if gv.shouldBacktrace(caller) {
+			stack = captureStack()
+		}
+
 		gv.tickClock()
 
-		gv.logWriteWrapper(mesg, "Something went wrong, could not log prepare send", Priority)
+		gv.logWriteWrapperWithEvent(mesg, "Something went wrong, could not log prepare send", Priority, "send", "", nil, caller, stack)
 
 		d := ClockPayload{Pid: gv.pid, VcMap: gv.currentVC.GetMap(), Payload: buf}
 
@@ -512,7 +691,6 @@ func (gv *GoLog) PrepareSendWithPriority(mesg string, buf interface{}, Priority
 		}
 
 		// return encodedBytes which can be sent off and received on the other end!
-		gv.mutex.Unlock()
 	}
 	return
 }
@@ -532,13 +710,16 @@ func (gv *GoLog) PrepareSend(mesg string, buf interface{}) []byte {
 	return gv.PrepareSendWithPriority(mesg, buf, gv.priority)
 }
 
-func (gv *GoLog) mergeIncomingClock(mesg string, e ClockPayload, Priority LogPriority) {
+func (gv *GoLog) mergeIncomingClock(mesg string, e ClockPayload, Priority LogPriority, caller string, stack string) {
+	// Capture the sender's clock before merging it into ours, so the
+	// "recv" record can carry the causal edge explicitly.
+	peerVc := copyVcMap(e.VcMap)
 
 	// First, tick the local clock
 	gv.tickClock()
 	gv.currentVC.Merge(e.VcMap)
 
-	gv.logWriteWrapper(mesg, "Something went Wrong, Could not Log!", Priority)
+	gv.logWriteWrapperWithEvent(mesg, "Something went Wrong, Could not Log!", Priority, "recv", e.Pid, peerVc, caller, stack)
 }
 
 /*
@@ -552,10 +733,19 @@ a packet. It unpacks the data by the program, the vector clock. It
 updates vector clock and logs it. and returns the user data
 */
 func (gv *GoLog) UnpackReceiveWithPriority(mesg string, buf []byte, unpack interface{}, Priority LogPriority) {
-
 	gv.mutex.Lock()
+	defer gv.mutex.Unlock()
 
 	if Priority >= gv.priority {
+		// callerLocation/captureStack are only worth paying for once we
+		// know this record will actually be logged; captureStack in
+		// particular dumps every goroutine's stack.
+		caller := callerLocation(1)
+		var stack string
+		if gv.shouldBacktrace(caller) {
+			stack = captureStack()
+		}
+
 		e := ClockPayload{}
 		e.Payload = unpack
 
@@ -566,8 +756,7 @@ func (gv *GoLog) UnpackReceiveWithPriority(mesg string, buf []byte, unpack inter
 		}
 
 		// Increment and merge the incoming clock
-		gv.mergeIncomingClock(mesg, e, Priority)
-		gv.mutex.Unlock()
+		gv.mergeIncomingClock(mesg, e, Priority, caller, stack)
 	}
 
 }
@@ -0,0 +1,31 @@
+package govec
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+//TestSinksWithoutLogToFile verifies that a GoLogConfig.Sinks entry still
+//receives real events (not just the "Initialization Complete" marker)
+//when LogToFile is false, i.e. logging is gated on whether there's
+//anything to write to, not on whether the default FileSink exists.
+func TestSinksWithoutLogToFile(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewWriterSink(&buf, false, FormatText)
+
+	config := GetDefaultConfig()
+	config.LogToFile = false
+	config.Sinks = []LogSink{sink}
+
+	gv := InitGoVector("test-pid", "unused", config)
+	buf.Reset() // drop the "Initialization Complete" marker
+
+	if !gv.LogLocalEvent("hello world") {
+		t.Fatal("LogLocalEvent reported failure")
+	}
+
+	if !strings.Contains(buf.String(), "hello world") {
+		t.Fatalf("sink never received the event with LogToFile false; buffer=%q", buf.String())
+	}
+}
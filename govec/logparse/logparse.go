@@ -0,0 +1,171 @@
+//Package logparse reads GoVector log output back into govec.LogRecord
+//values, for either of GoVector's on-disk formats (govec.FormatText,
+//govec.FormatJSON). It exists so tools that currently scrape GoVector's
+//text log files (ShiViz exporters, ad-hoc scripts) have a single place
+//to parse both formats instead of re-implementing it, and so FormatJSON
+//consumers (jq, log shippers) can go through the same struct as the rest
+//of GoVector.
+package logparse
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DistributedClocks/GoVector/govec"
+)
+
+//jsonRecord mirrors the FormatJSON shape written by govec (see
+//govec's internal jsonRecord); it's kept here rather than exported from
+//govec so this package owns its own wire contract with the file.
+type jsonRecord struct {
+	Timestamp int64             `json:"ts"`
+	Pid       string            `json:"pid"`
+	Vc        map[string]uint64 `json:"vc"`
+	Priority  string            `json:"priority"`
+	Message   string            `json:"msg"`
+	Event     string            `json:"event,omitempty"`
+	Peer      string            `json:"peer,omitempty"`
+	PeerVc    map[string]uint64 `json:"peer_vc,omitempty"`
+	Caller    string            `json:"caller,omitempty"`
+	Stack     string            `json:"stack,omitempty"`
+}
+
+//priorityByName maps a FormatJSON "priority" string back to its
+//govec.LogPriority value.
+var priorityByName = map[string]govec.LogPriority{
+	"DEBUG":   govec.DEBUG,
+	"INFO":    govec.INFO,
+	"WARNING": govec.WARNING,
+	"ERROR":   govec.ERROR,
+	"FATAL":   govec.FATAL,
+}
+
+//Parse reads the log records written to r and returns them as
+//govec.LogRecord values. format must match how r was written
+//(govec.FormatText or govec.FormatJSON); useTimestamps must match the
+//GoLogConfig.UseTimestamps the records were written with and is ignored
+//for FormatJSON, which always carries its own timestamp.
+func Parse(r io.Reader, format govec.LogFormat, useTimestamps bool) ([]govec.LogRecord, error) {
+	if format == govec.FormatJSON {
+		return parseJSON(r)
+	}
+	return parseText(r, useTimestamps)
+}
+
+//parseText reads GoVector's historical two-line "PID VCString\nMessage"
+//format. Priority, Event, and Peer can't be recovered from it and are
+//left at their zero value. A message may be followed by a Stack, whose
+//lines are each prefixed with govec.TextStackLinePrefix by
+//formatTextRecord; those are consumed back into Stack rather than being
+//mistaken for the next record's header.
+func parseText(r io.Reader, useTimestamps bool) ([]govec.LogRecord, error) {
+	scanner := bufio.NewScanner(r)
+	var lines []string
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var records []govec.LogRecord
+	for i := 0; i < len(lines); {
+		header := lines[i]
+		i++
+		if header == "" || strings.HasPrefix(header, "=== Execution #") {
+			continue
+		}
+
+		if i >= len(lines) {
+			return nil, fmt.Errorf("logparse: header line %q has no message line", header)
+		}
+		message := lines[i]
+		i++
+
+		var stackLines []string
+		for i < len(lines) && strings.HasPrefix(lines[i], govec.TextStackLinePrefix) {
+			stackLines = append(stackLines, strings.TrimPrefix(lines[i], govec.TextStackLinePrefix))
+			i++
+		}
+
+		record, err := parseTextRecord(header, message, useTimestamps)
+		if err != nil {
+			return nil, err
+		}
+		if len(stackLines) > 0 {
+			record.Stack = strings.Join(stackLines, "\n") + "\n"
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func parseTextRecord(header, message string, useTimestamps bool) (govec.LogRecord, error) {
+	var timestamp time.Time
+	rest := header
+
+	if useTimestamps {
+		parts := strings.SplitN(header, " ", 2)
+		if len(parts) != 2 {
+			return govec.LogRecord{}, fmt.Errorf("logparse: malformed header line %q", header)
+		}
+		nanos, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			return govec.LogRecord{}, fmt.Errorf("logparse: malformed timestamp in %q: %v", header, err)
+		}
+		timestamp = time.Unix(0, nanos)
+		rest = parts[1]
+	}
+
+	parts := strings.SplitN(rest, " ", 2)
+	if len(parts) != 2 {
+		return govec.LogRecord{}, fmt.Errorf("logparse: malformed header line %q", header)
+	}
+
+	return govec.LogRecord{
+		Pid:       parts[0],
+		VCString:  parts[1],
+		Message:   message,
+		Timestamp: timestamp,
+	}, nil
+}
+
+//parseJSON reads one FormatJSON record per line. Lines that aren't valid
+//JSON (e.g. an appended "=== Execution # ... ===" marker, which is
+//always written as plain text) are skipped rather than treated as a
+//parse error.
+func parseJSON(r io.Reader) ([]govec.LogRecord, error) {
+	scanner := bufio.NewScanner(r)
+	var records []govec.LogRecord
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		var rec jsonRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			continue
+		}
+
+		records = append(records, govec.LogRecord{
+			Pid:       rec.Pid,
+			Message:   rec.Message,
+			Priority:  priorityByName[rec.Priority],
+			Timestamp: time.Unix(0, rec.Timestamp),
+			VcMap:     rec.Vc,
+			Event:     rec.Event,
+			Peer:      rec.Peer,
+			PeerVc:    rec.PeerVc,
+			Caller:    rec.Caller,
+			Stack:     rec.Stack,
+		})
+	}
+	return records, scanner.Err()
+}
@@ -0,0 +1,46 @@
+package logparse
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/DistributedClocks/GoVector/govec"
+)
+
+//TestParseTextWithEmbeddedStack verifies that a FormatText record
+//carrying a multi-line Stack (as written when GoLogConfig.BacktraceAt
+//fires) round-trips through Parse without desyncing the records that
+//follow it.
+func TestParseTextWithEmbeddedStack(t *testing.T) {
+	var buffer strings.Builder
+	buffer.WriteString("p1 p1:1\n")
+	buffer.WriteString("first event\n")
+	buffer.WriteString(govec.TextStackLinePrefix + "goroutine 1 [running]:\n")
+	buffer.WriteString(govec.TextStackLinePrefix + "main.main()\n")
+	buffer.WriteString("p1 p1:2\n")
+	buffer.WriteString("second event\n")
+
+	records, err := Parse(strings.NewReader(buffer.String()), govec.FormatText, false)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2: %+v", len(records), records)
+	}
+
+	if records[0].Message != "first event" {
+		t.Errorf("records[0].Message = %q, want %q", records[0].Message, "first event")
+	}
+	wantStack := "goroutine 1 [running]:\nmain.main()\n"
+	if records[0].Stack != wantStack {
+		t.Errorf("records[0].Stack = %q, want %q", records[0].Stack, wantStack)
+	}
+
+	if records[1].Message != "second event" {
+		t.Errorf("records[1].Message = %q, want %q", records[1].Message, "second event")
+	}
+	if records[1].Stack != "" {
+		t.Errorf("records[1].Stack = %q, want empty", records[1].Stack)
+	}
+}
@@ -0,0 +1,454 @@
+package govec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/syslog"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//LogRecord is the information produced for a single logged event. It is
+//handed to every configured LogSink so that sinks can decide how (and
+//whether) to persist or forward it.
+type LogRecord struct {
+	Pid       string
+	VCString  string
+	Message   string
+	Priority  LogPriority
+	Timestamp time.Time
+
+	//VcMap is the map form of the vector clock in effect when this
+	//record was produced. A FileSink rotation marker carries the clock
+	//of the last record written before the rotation (nil if there was
+	//none).
+	VcMap map[string]uint64
+
+	//Event classifies what produced this record: "local", "send", or
+	//"recv", or "fatal" for the final record written by
+	//GoLogConfig.FatalExits. It is empty for records, like the
+	//initialization marker, that don't come from one of those calls.
+	Event string
+
+	//Peer identifies the sender of a "recv" record: its process ID for
+	//UnpackReceive, or the peerPid passed to MergeReceivedClock (the
+	//request's remote address for govec/transport's HTTP middleware,
+	//empty for its gRPC interceptors). It is empty for other events.
+	Peer string
+
+	//PeerVc is the sender's vector clock as it stood before being merged
+	//into the local clock, so a "recv" record makes the causal edge to
+	//its sender explicit. It is nil except for "recv" events.
+	PeerVc map[string]uint64
+
+	//Caller is the "file:line" that called the GoLog method which
+	//logged this record (LogLocalEventWithPriority,
+	//PrepareSendWithPriority, UnpackReceiveWithPriority,
+	//PrepareSendClock, ...). A record logged through LogLocalEvent,
+	//PrepareSend, or UnpackReceive names the line inside govec where
+	//that method calls its WithPriority form. It is empty if the call
+	//site couldn't be resolved.
+	Caller string
+
+	//Stack is a full goroutine dump (from runtime.Stack), captured at
+	//the same moment as VcMap so it is causally anchored by this
+	//record's clock. It is only populated when Caller matched a
+	//GoLogConfig.BacktraceAt location, or for the final record emitted
+	//by GoLogConfig.FatalExits.
+	Stack string
+}
+
+//LogFormat selects how a sink renders a LogRecord to text.
+type LogFormat int
+
+//LogFormat values accepted by GoLogConfig.Format.
+const (
+	//FormatText is GoVector's historical two-line "PID VCString\nMessage"
+	//format.
+	FormatText LogFormat = iota
+
+	//FormatJSON renders one JSON object per record, one per line, with
+	//the event/peer metadata that FormatText has no room for.
+	FormatJSON
+)
+
+//LogSink is the destination for GoVector log records. GoLog never talks
+//to a file, socket, or syslog daemon directly; it hands every record to
+//its configured sinks instead, so the same vector-clock events can be
+//written to disk, streamed to a collector, or forwarded to syslog without
+//changing anything in GoLog itself.
+type LogSink interface {
+	// Emit is called once per logged event. Implementations may buffer
+	// the record internally and only persist it once Flush is called.
+	Emit(record LogRecord) error
+
+	// Flush persists any buffered records. It is called whenever the
+	// GoLog it is attached to is flushed, and should be safe to call
+	// even if there is nothing buffered.
+	Flush() error
+}
+
+//copyVcMap returns an independent copy of vc, so a LogRecord handed to a
+//sink can't be mutated by later clock ticks on the same GoLog.
+func copyVcMap(vc map[string]uint64) map[string]uint64 {
+	cp := make(map[string]uint64, len(vc))
+	for pid, count := range vc {
+		cp[pid] = count
+	}
+	return cp
+}
+
+//TextStackLinePrefix prefixes every line of a Stack trace embedded in a
+//FormatText record. FormatText otherwise has exactly one line per field,
+//so a raw multi-line stack dump would be indistinguishable from the next
+//record's header; prefixing each of its lines lets a reader (see
+//govec/logparse) tell a stack continuation line from a real header.
+const TextStackLinePrefix = "\t"
+
+//formatTextRecord renders a LogRecord using the classic two-line
+//"PID VCString\nMessage" format that GoVector has always written to its
+//log files. A non-empty Stack is appended as additional lines, each
+//prefixed with TextStackLinePrefix so it stays framed as part of this
+//record instead of being mistaken for the next one.
+func formatTextRecord(record LogRecord, useTimestamps bool) string {
+	var buffer bytes.Buffer
+	if useTimestamps {
+		buffer.WriteString(strconv.FormatInt(record.Timestamp.UnixNano(), 10))
+		buffer.WriteString(" ")
+	}
+	buffer.WriteString(record.Pid)
+	buffer.WriteString(" ")
+	buffer.WriteString(record.VCString)
+	buffer.WriteString("\n")
+	buffer.WriteString(record.Message)
+	buffer.WriteString("\n")
+	if record.Stack != "" {
+		for _, line := range strings.Split(strings.TrimRight(record.Stack, "\n"), "\n") {
+			buffer.WriteString(TextStackLinePrefix)
+			buffer.WriteString(line)
+			buffer.WriteString("\n")
+		}
+	}
+	return buffer.String()
+}
+
+//jsonRecord is the on-disk shape of a LogRecord under FormatJSON, one
+//object per line.
+type jsonRecord struct {
+	Timestamp int64             `json:"ts"`
+	Pid       string            `json:"pid"`
+	Vc        map[string]uint64 `json:"vc"`
+	Priority  string            `json:"priority"`
+	Message   string            `json:"msg"`
+	Event     string            `json:"event,omitempty"`
+	Peer      string            `json:"peer,omitempty"`
+	PeerVc    map[string]uint64 `json:"peer_vc,omitempty"`
+	Caller    string            `json:"caller,omitempty"`
+	Stack     string            `json:"stack,omitempty"`
+}
+
+//formatJSONRecord renders a LogRecord as a single line of JSON, the
+//FormatJSON counterpart to formatTextRecord.
+func formatJSONRecord(record LogRecord) (string, error) {
+	encoded, err := json.Marshal(jsonRecord{
+		Timestamp: record.Timestamp.UnixNano(),
+		Pid:       record.Pid,
+		Vc:        record.VcMap,
+		Priority:  record.Priority.name(),
+		Message:   record.Message,
+		Event:     record.Event,
+		Peer:      record.Peer,
+		PeerVc:    record.PeerVc,
+		Caller:    record.Caller,
+		Stack:     record.Stack,
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(encoded) + "\n", nil
+}
+
+//renderRecord formats record according to format. It is the one place
+//FileSink and WriterSink go through, so both stay in sync as formats are
+//added.
+func renderRecord(record LogRecord, useTimestamps bool, format LogFormat) (string, error) {
+	if format == FormatJSON {
+		return formatJSONRecord(record)
+	}
+	return formatTextRecord(record, useTimestamps), nil
+}
+
+//FileSink is the default LogSink, writing text records to a local file.
+//It preserves GoVector's historical behaviour: the file is truncated on
+//creation unless appendLog is set, in which case an execution marker is
+//appended instead. It optionally rotates the file by size and/or age;
+//see RotationConfig.
+type FileSink struct {
+	path          string
+	appendLog     bool
+	useTimestamps bool
+	format        LogFormat
+	rotation      RotationConfig
+
+	mutex     sync.Mutex
+	buffer    bytes.Buffer
+	createdAt time.Time
+	lastVcMap map[string]uint64
+}
+
+//NewFileSink prepares path for logging and returns a FileSink that writes
+//to it. If appendLog is false any existing file at path is truncated; if
+//appendLog is true new executions are appended after an "=== Execution #
+//... ===" marker. format selects FormatText or FormatJSON records.
+//rotation configures size/age-based rotation; its zero value disables
+//rotation entirely.
+func NewFileSink(path string, appendLog bool, useTimestamps bool, format LogFormat, rotation RotationConfig) (*FileSink, error) {
+	sink := &FileSink{path: path, appendLog: appendLog, useTimestamps: useTimestamps, format: format, rotation: rotation, createdAt: time.Now()}
+
+	_, err := os.Stat(path)
+	if err == nil {
+		if !appendLog {
+			os.Remove(path)
+		} else {
+			executionnumber := time.Now().Format(time.UnixDate)
+			sink.buffer.WriteString("=== Execution #" + executionnumber + "  ===\n")
+			return sink, sink.Flush()
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	file.Close()
+
+	if appendLog {
+		executionnumber := time.Now().Format(time.UnixDate)
+		sink.buffer.WriteString("=== Execution #" + executionnumber + "  ===\n")
+		return sink, sink.Flush()
+	}
+
+	return sink, nil
+}
+
+//Emit appends the formatted record to the sink's internal buffer. The
+//record is not written to disk until Flush is called.
+func (f *FileSink) Emit(record LogRecord) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	line, err := renderRecord(record, f.useTimestamps, f.format)
+	if err != nil {
+		return err
+	}
+	f.buffer.WriteString(line)
+	if record.VcMap != nil {
+		f.lastVcMap = record.VcMap
+	}
+	return nil
+}
+
+//Flush appends any buffered records to the underlying file, then rotates
+//it if the configured size or age threshold has been exceeded.
+func (f *FileSink) Flush() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.buffer.Len() > 0 {
+		file, err := os.OpenFile(f.path, os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		_, writeErr := file.WriteString(f.buffer.String())
+		file.Close()
+		if writeErr != nil {
+			return writeErr
+		}
+		f.buffer.Reset()
+	}
+
+	if f.shouldRotate() {
+		return f.rotate()
+	}
+	return nil
+}
+
+//WriterSink writes text records to an arbitrary io.Writer, useful for
+//tests or for piping GoVector's output into something other than a file
+//(stdout, a bytes.Buffer, an os.Pipe, ...).
+type WriterSink struct {
+	w             io.Writer
+	useTimestamps bool
+	format        LogFormat
+
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+}
+
+//NewWriterSink returns a LogSink that writes records to w in the given
+//format.
+func NewWriterSink(w io.Writer, useTimestamps bool, format LogFormat) *WriterSink {
+	return &WriterSink{w: w, useTimestamps: useTimestamps, format: format}
+}
+
+//Emit appends the formatted record to the sink's internal buffer.
+func (w *WriterSink) Emit(record LogRecord) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	line, err := renderRecord(record, w.useTimestamps, w.format)
+	if err != nil {
+		return err
+	}
+	w.buffer.WriteString(line)
+	return nil
+}
+
+//Flush writes any buffered records to the underlying io.Writer.
+func (w *WriterSink) Flush() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.buffer.Len() == 0 {
+		return nil
+	}
+	if _, err := w.w.Write(w.buffer.Bytes()); err != nil {
+		return err
+	}
+	w.buffer.Reset()
+	return nil
+}
+
+//SyslogSink forwards records to a syslog daemon, either the local one or
+//a remote collector addressed over TCP/UDP.
+type SyslogSink struct {
+	writer *syslog.Writer
+}
+
+//NewSyslogSink connects to the local syslog daemon and returns a LogSink
+//that writes records to it, tagged with tag.
+func NewSyslogSink(tag string) (*SyslogSink, error) {
+	w, err := syslog.New(syslog.LOG_INFO, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &SyslogSink{writer: w}, nil
+}
+
+//NewRemoteSyslogSink dials a remote syslog collector over network
+//("tcp" or "udp") at raddr and returns a LogSink that writes records to
+//it. Messages use log/syslog's own header ("<PRI>timestamp hostname
+//tag[pid]: msg", RFC 3164 style with an RFC 3339 timestamp); RFC 5424
+//framing is not produced.
+func NewRemoteSyslogSink(network, raddr, tag string) (*SyslogSink, error) {
+	w, err := syslog.Dial(network, raddr, syslog.LOG_INFO, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &SyslogSink{writer: w}, nil
+}
+
+func (s *SyslogSink) syslogLevel(priority LogPriority) func(string) error {
+	switch priority {
+	case DEBUG:
+		return s.writer.Debug
+	case INFO:
+		return s.writer.Info
+	case WARNING:
+		return s.writer.Warning
+	case ERROR:
+		return s.writer.Err
+	case FATAL:
+		return s.writer.Crit
+	default:
+		return s.writer.Info
+	}
+}
+
+//Emit writes the record to syslog immediately; syslog has no notion of
+//buffering, so Emit never defers work to Flush.
+func (s *SyslogSink) Emit(record LogRecord) error {
+	line := fmt.Sprintf("%s %s | %s", record.Pid, record.VCString, record.Message)
+	return s.syslogLevel(record.Priority)(line)
+}
+
+//Flush is a no-op for SyslogSink, since Emit already writes through.
+func (s *SyslogSink) Flush() error {
+	return nil
+}
+
+//NetworkRecord is the JSON shape written, one per line, by NetworkSink.
+//It mirrors LogRecord so a central collector can reassemble causal
+//history from many processes without understanding GoVector's internal
+//text format.
+type NetworkRecord struct {
+	Pid       string            `json:"pid"`
+	VC        string            `json:"vc"`
+	Message   string            `json:"msg"`
+	Priority  string            `json:"priority"`
+	Timestamp int64             `json:"ts"`
+	Extra     map[string]string `json:"extra,omitempty"`
+}
+
+//NetworkSink streams newline-delimited JSON records to a TCP collector,
+//so a central process (e.g. a live Shiviz feeder) can merge logs from
+//many GoVector processes as they happen instead of after the fact.
+type NetworkSink struct {
+	conn   net.Conn
+	mutex  sync.Mutex
+	writer *bufio.Writer
+}
+
+//NewNetworkSink dials addr over TCP and returns a LogSink that streams
+//newline-delimited JSON records to it as they are emitted.
+func NewNetworkSink(addr string) (*NetworkSink, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &NetworkSink{conn: conn, writer: bufio.NewWriter(conn)}, nil
+}
+
+//Emit encodes the record as a JSON line and writes it to the sink's
+//buffered writer; the bytes reach the collector connection on Flush (or
+//once the buffer fills).
+func (n *NetworkSink) Emit(record LogRecord) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	encoded, err := json.Marshal(NetworkRecord{
+		Pid:       record.Pid,
+		VC:        record.VCString,
+		Message:   record.Message,
+		Priority:  record.Priority.getPrefixString(),
+		Timestamp: record.Timestamp.UnixNano(),
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := n.writer.Write(encoded); err != nil {
+		return err
+	}
+	return n.writer.WriteByte('\n')
+}
+
+//Flush pushes any buffered bytes out onto the TCP connection.
+func (n *NetworkSink) Flush() error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return n.writer.Flush()
+}
+
+//Close tears down the collector connection.
+func (n *NetworkSink) Close() error {
+	return n.conn.Close()
+}
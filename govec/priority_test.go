@@ -0,0 +1,70 @@
+package govec
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+//withTimeout runs fn in a goroutine and fails the test if fn hasn't
+//returned within d, so a regression that deadlocks gv.mutex reports as a
+//test failure instead of hanging `go test` forever.
+func withTimeout(t *testing.T, d time.Duration, fn func()) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		fn()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(d):
+		t.Fatal("timed out; gv.mutex is likely still held")
+	}
+}
+
+//TestPrepareSendWithPriorityUnlocksWhenFiltered verifies that a Priority
+//below the configured threshold still releases gv.mutex. Both
+//PrepareSendWithPriority and UnpackReceiveWithPriority used to pair
+//gv.mutex.Lock() with an Unlock() reachable only from inside the
+//priority-gated branch, so a filtered-out call left the mutex held
+//forever and hung every later call on the same GoLog.
+func TestPrepareSendWithPriorityUnlocksWhenFiltered(t *testing.T) {
+	config := GetDefaultConfig()
+	config.LogToFile = false
+	config.Sinks = []LogSink{NewWriterSink(&bytes.Buffer{}, false, FormatText)}
+	config.Priority = INFO
+	gv := InitGoVector("test-pid", "unused", config)
+
+	withTimeout(t, time.Second, func() {
+		gv.PrepareSendWithPriority("filtered send", 42, DEBUG)
+	})
+	withTimeout(t, time.Second, func() {
+		if !gv.LogLocalEvent("still usable") {
+			t.Error("LogLocalEvent reported failure")
+		}
+	})
+}
+
+//TestUnpackReceiveWithPriorityUnlocksWhenFiltered is
+//TestPrepareSendWithPriorityUnlocksWhenFiltered's counterpart for
+//UnpackReceiveWithPriority.
+func TestUnpackReceiveWithPriorityUnlocksWhenFiltered(t *testing.T) {
+	config := GetDefaultConfig()
+	config.LogToFile = false
+	config.Sinks = []LogSink{NewWriterSink(&bytes.Buffer{}, false, FormatText)}
+	config.Priority = INFO
+	gv := InitGoVector("test-pid", "unused", config)
+
+	encoded := gv.PrepareSend("send", 42)
+
+	withTimeout(t, time.Second, func() {
+		var payload int
+		gv.UnpackReceiveWithPriority("filtered receive", encoded, &payload, DEBUG)
+	})
+	withTimeout(t, time.Second, func() {
+		if !gv.LogLocalEvent("still usable") {
+			t.Error("LogLocalEvent reported failure")
+		}
+	})
+}
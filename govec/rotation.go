@@ -0,0 +1,210 @@
+package govec
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//RotationConfig controls whether and how a FileSink rotates its
+//underlying file. The zero value disables rotation.
+type RotationConfig struct {
+	//MaxSizeBytes rotates the file once it reaches this size. Zero
+	//disables size-based rotation.
+	MaxSizeBytes int64
+
+	//MaxAgeSeconds rotates the file once it has been open this long.
+	//Zero disables age-based rotation.
+	MaxAgeSeconds int64
+
+	//MaxBackups caps how many rotated segments are kept; the oldest
+	//are deleted first. Zero keeps every segment.
+	MaxBackups int
+
+	//Compress gzips a segment immediately after it is rotated out.
+	Compress bool
+}
+
+//enabled reports whether any rotation trigger is configured.
+func (r RotationConfig) enabled() bool {
+	return r.MaxSizeBytes > 0 || r.MaxAgeSeconds > 0
+}
+
+//shouldRotate reports whether f's current file has exceeded the
+//configured size or age threshold. The caller must hold f.mutex.
+func (f *FileSink) shouldRotate() bool {
+	if !f.rotation.enabled() {
+		return false
+	}
+
+	if f.rotation.MaxAgeSeconds > 0 && time.Since(f.createdAt) >= time.Duration(f.rotation.MaxAgeSeconds)*time.Second {
+		return true
+	}
+
+	if f.rotation.MaxSizeBytes > 0 {
+		info, err := os.Stat(f.path)
+		if err == nil && info.Size() >= f.rotation.MaxSizeBytes {
+			return true
+		}
+	}
+
+	return false
+}
+
+//rotate renames f's current file aside (optionally gzipping it),
+//replaces it with a fresh empty file, and writes a marker recording the
+//current vector clock into both segments so downstream tools can stitch
+//them back into one causal history. The caller must hold f.mutex.
+func (f *FileSink) rotate() error {
+	marker, err := renderRecord(LogRecord{
+		Message:   "=== Log Rotated: clock=" + formatVcMap(f.lastVcMap) + " ===",
+		VcMap:     f.lastVcMap,
+		Timestamp: time.Now(),
+	}, f.useTimestamps, f.format)
+	if err != nil {
+		return err
+	}
+
+	if err := appendStringToFile(f.path, marker); err != nil {
+		return err
+	}
+
+	backupPath := f.path + "." + time.Now().Format("20060102T150405.000000000")
+	if err := os.Rename(f.path, backupPath); err != nil {
+		return err
+	}
+
+	if f.rotation.Compress {
+		if err := gzipAndRemove(backupPath); err != nil {
+			return err
+		}
+	}
+
+	file, err := os.Create(f.path)
+	if err != nil {
+		return err
+	}
+	file.Close()
+	f.createdAt = time.Now()
+
+	if err := appendStringToFile(f.path, marker); err != nil {
+		return err
+	}
+
+	f.pruneBackups()
+	return nil
+}
+
+//pruneBackups deletes rotated segments beyond MaxBackups, oldest first.
+//The caller must hold f.mutex.
+func (f *FileSink) pruneBackups() {
+	if f.rotation.MaxBackups <= 0 {
+		return
+	}
+
+	matches, err := filepath.Glob(f.path + ".*")
+	if err != nil {
+		return
+	}
+	sort.Strings(matches)
+
+	if len(matches) <= f.rotation.MaxBackups {
+		return
+	}
+	for _, stale := range matches[:len(matches)-f.rotation.MaxBackups] {
+		os.Remove(stale)
+	}
+}
+
+func appendStringToFile(path string, s string) error {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.WriteString(s)
+	return err
+}
+
+func gzipAndRemove(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(path + ".gz")
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		gz.Close()
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	src.Close()
+	return os.Remove(path)
+}
+
+//formatVcMap renders a vector clock map as a deterministic, sorted
+//"pid:count,pid:count" string for embedding in rotation markers.
+func formatVcMap(vc map[string]uint64) string {
+	pids := make([]string, 0, len(vc))
+	for pid := range vc {
+		pids = append(pids, pid)
+	}
+	sort.Strings(pids)
+
+	parts := make([]string, 0, len(pids))
+	for _, pid := range pids {
+		parts = append(parts, pid+":"+strconv.FormatUint(vc[pid], 10))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+//startRotationTicker periodically flushes gv's sinks so that rotation
+//based on size/age is checked even while buffered writes are enabled and
+//no new events are being logged to trigger it.
+func (gv *GoLog) startRotationTicker(interval time.Duration) {
+	gv.rotationTicker = time.NewTicker(interval)
+	gv.rotationDone = make(chan struct{})
+
+	ticker := gv.rotationTicker
+	done := gv.rotationDone
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				gv.Flush()
+			case <-done:
+				return
+			}
+		}
+	}()
+}
+
+//Close stops any background rotation ticker and flushes every sink. It
+//should be called before a process using a Buffered, rotating GoLog
+//exits, so no buffered records or pending rotations are lost.
+func (gv *GoLog) Close() error {
+	if gv.rotationTicker != nil {
+		gv.rotationTicker.Stop()
+		close(gv.rotationDone)
+	}
+	if !gv.Flush() {
+		return fmt.Errorf("govec: failed to flush one or more sinks on close")
+	}
+	return nil
+}
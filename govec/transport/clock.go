@@ -0,0 +1,42 @@
+//Package transport auto-instruments net/http and gRPC calls with a
+//GoVector vector clock, so causal tracing works across a service mesh
+//without every handler hand-wrapping its payload in PrepareSend/
+//UnpackReceive. Both transports carry the clock the same way: msgpack-
+//encode it, then base64-encode that, into a single text value keyed by
+//ClockHeader (an HTTP header, or the equivalent gRPC metadata key).
+package transport
+
+import (
+	"encoding/base64"
+
+	"github.com/vmihailenco/msgpack"
+)
+
+//ClockHeader is the name GoVector's middleware and interceptors
+//serialize the vector clock under: base64-encoded msgpack of a
+//map[string]uint64. gRPC lower-cases metadata keys, so the same name
+//also identifies the clock in gRPC metadata.
+const ClockHeader = "X-GoVector-Clock"
+
+//encodeClockHeader msgpack- then base64-encodes vc into a single value
+//safe for a text header.
+func encodeClockHeader(vc map[string]uint64) (string, error) {
+	encoded, err := msgpack.Marshal(vc)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(encoded), nil
+}
+
+//decodeClockHeader reverses encodeClockHeader.
+func decodeClockHeader(header string) (map[string]uint64, error) {
+	raw, err := base64.StdEncoding.DecodeString(header)
+	if err != nil {
+		return nil, err
+	}
+	var vc map[string]uint64
+	if err := msgpack.Unmarshal(raw, &vc); err != nil {
+		return nil, err
+	}
+	return vc, nil
+}
@@ -0,0 +1,100 @@
+package transport
+
+import (
+	"context"
+
+	"github.com/DistributedClocks/GoVector/govec"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+//UnaryClientInterceptor returns a grpc.UnaryClientInterceptor that
+//attaches gv's current vector clock to the outgoing call's metadata
+//under ClockHeader before invoking it, logging a "send" event tagged
+//with the method name.
+func UnaryClientInterceptor(gv *govec.GoLog) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(attachOutgoingClock(ctx, gv, method), method, req, reply, cc, opts...)
+	}
+}
+
+//StreamClientInterceptor returns a grpc.StreamClientInterceptor that
+//attaches gv's current vector clock to the stream's metadata under
+//ClockHeader before opening it, logging a "send" event tagged with the
+//method name.
+func StreamClientInterceptor(gv *govec.GoLog) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(attachOutgoingClock(ctx, gv, method), desc, cc, method, opts...)
+	}
+}
+
+//UnaryServerInterceptor returns a grpc.UnaryServerInterceptor that
+//merges an incoming call's ClockHeader metadata into gv, logging a
+//"recv" event tagged with the method's full name, and attaches the
+//resulting clock to the handler's context under
+//govec.VClockFromContext.
+func UnaryServerInterceptor(gv *govec.GoLog) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return handler(mergeIncomingClock(ctx, gv, info.FullMethod), req)
+	}
+}
+
+//StreamServerInterceptor returns a grpc.StreamServerInterceptor that
+//merges an incoming stream's ClockHeader metadata into gv, logging a
+//"recv" event tagged with the method's full name, and wraps ss so the
+//handler's ss.Context() carries the resulting clock.
+func StreamServerInterceptor(gv *govec.GoLog) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := mergeIncomingClock(ss.Context(), gv, info.FullMethod)
+		return handler(srv, &clockServerStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+//attachOutgoingClock returns ctx with gv's current vector clock, encoded
+//under ClockHeader, added to its outgoing gRPC metadata.
+func attachOutgoingClock(ctx context.Context, gv *govec.GoLog, method string) context.Context {
+	vc := gv.PrepareSendClock(method)
+
+	encoded, err := encodeClockHeader(vc)
+	if err != nil {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, ClockHeader, encoded)
+}
+
+//mergeIncomingClock merges the ClockHeader carried by ctx's incoming
+//gRPC metadata, if any, into gv and returns ctx with the resulting clock
+//attached under govec.VClockFromContext. ctx is returned unchanged if no
+//(or an unparseable) clock is present.
+func mergeIncomingClock(ctx context.Context, gv *govec.GoLog, method string) context.Context {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx
+	}
+
+	values := md.Get(ClockHeader)
+	if len(values) == 0 {
+		return ctx
+	}
+
+	peerVc, err := decodeClockHeader(values[0])
+	if err != nil {
+		return ctx
+	}
+
+	vc := gv.MergeReceivedClock(method, "", peerVc)
+	return govec.ContextWithVClock(ctx, vc)
+}
+
+//clockServerStream overrides grpc.ServerStream's Context so a handler
+//sees the context produced by mergeIncomingClock; grpc.ServerStream
+//doesn't otherwise let a StreamServerInterceptor replace it.
+type clockServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+//Context implements grpc.ServerStream.
+func (s *clockServerStream) Context() context.Context {
+	return s.ctx
+}
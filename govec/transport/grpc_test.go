@@ -0,0 +1,174 @@
+package transport
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DistributedClocks/GoVector/govec"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+//TestUnaryClientInterceptorAttachesClockHeader verifies that the client
+//interceptor attaches gv's current vector clock to the outgoing call's
+//metadata before invoking it.
+func TestUnaryClientInterceptorAttachesClockHeader(t *testing.T) {
+	gv := newTestGoLog("client")
+	interceptor := UnaryClientInterceptor(gv)
+
+	var gotCtx context.Context
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		gotCtx = ctx
+		return nil
+	}
+
+	if err := interceptor(context.Background(), "/svc/Method", nil, nil, nil, invoker); err != nil {
+		t.Fatalf("interceptor: %v", err)
+	}
+
+	md, ok := metadata.FromOutgoingContext(gotCtx)
+	if !ok {
+		t.Fatal("invoker's context has no outgoing metadata")
+	}
+	values := md.Get(ClockHeader)
+	if len(values) == 0 {
+		t.Fatal("outgoing metadata has no ClockHeader")
+	}
+	vc, err := decodeClockHeader(values[0])
+	if err != nil {
+		t.Fatalf("decodeClockHeader: %v", err)
+	}
+	if _, ok := vc["client"]; !ok {
+		t.Errorf("decoded clock is missing the client's own pid: %v", vc)
+	}
+}
+
+//TestUnaryServerInterceptorMergesClockHeader verifies that the server
+//interceptor merges an incoming call's ClockHeader into gv and attaches
+//the resulting clock to the handler's context.
+func TestUnaryServerInterceptorMergesClockHeader(t *testing.T) {
+	serverGv := newTestGoLog("server")
+	clientGv := newTestGoLog("client")
+	interceptor := UnaryServerInterceptor(serverGv)
+
+	sentVc := clientGv.PrepareSendClock("/svc/Method")
+	encoded, err := encodeClockHeader(sentVc)
+	if err != nil {
+		t.Fatalf("encodeClockHeader: %v", err)
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(ClockHeader, encoded))
+
+	var gotVc map[string]uint64
+	var gotOk bool
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		gotVc, gotOk = govec.VClockFromContext(ctx)
+		return nil, nil
+	}
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/svc/Method"}
+	if _, err := interceptor(ctx, nil, info, handler); err != nil {
+		t.Fatalf("interceptor: %v", err)
+	}
+
+	if !gotOk {
+		t.Fatal("handler's context has no vector clock")
+	}
+	if gotVc["client"] != sentVc["client"] {
+		t.Errorf("gotVc[client] = %d, want %d", gotVc["client"], sentVc["client"])
+	}
+}
+
+//TestUnaryServerInterceptorPassesThroughMissingOrMalformedHeader verifies
+//that a call without a ClockHeader, or with one that doesn't decode, is
+//passed to handler unchanged rather than merging garbage into gv or
+//panicking.
+func TestUnaryServerInterceptorPassesThroughMissingOrMalformedHeader(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		setHdr bool
+	}{
+		{name: "missing header", setHdr: false},
+		{name: "malformed header", header: "not-valid-base64!!!", setHdr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			serverGv := newTestGoLog("server")
+			interceptor := UnaryServerInterceptor(serverGv)
+
+			ctx := context.Background()
+			if test.setHdr {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(ClockHeader, test.header))
+			} else {
+				ctx = metadata.NewIncomingContext(ctx, metadata.MD{})
+			}
+
+			var called bool
+			var gotOk bool
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				called = true
+				_, gotOk = govec.VClockFromContext(ctx)
+				return nil, nil
+			}
+
+			info := &grpc.UnaryServerInfo{FullMethod: "/svc/Method"}
+			if _, err := interceptor(ctx, nil, info, handler); err != nil {
+				t.Fatalf("interceptor: %v", err)
+			}
+
+			if !called {
+				t.Fatal("handler was never called")
+			}
+			if gotOk {
+				t.Error("expected no vector clock on the context, got one")
+			}
+		})
+	}
+}
+
+//TestStreamServerInterceptorMergesClockHeader verifies that the stream
+//server interceptor wraps ss so Context() carries the merged clock.
+func TestStreamServerInterceptorMergesClockHeader(t *testing.T) {
+	serverGv := newTestGoLog("server")
+	clientGv := newTestGoLog("client")
+	interceptor := StreamServerInterceptor(serverGv)
+
+	sentVc := clientGv.PrepareSendClock("/svc/Stream")
+	encoded, err := encodeClockHeader(sentVc)
+	if err != nil {
+		t.Fatalf("encodeClockHeader: %v", err)
+	}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(ClockHeader, encoded))
+
+	var gotVc map[string]uint64
+	var gotOk bool
+	handler := func(srv interface{}, stream grpc.ServerStream) error {
+		gotVc, gotOk = govec.VClockFromContext(stream.Context())
+		return nil
+	}
+
+	info := &grpc.StreamServerInfo{FullMethod: "/svc/Stream"}
+	if err := interceptor(nil, &fakeServerStream{ctx: ctx}, info, handler); err != nil {
+		t.Fatalf("interceptor: %v", err)
+	}
+
+	if !gotOk {
+		t.Fatal("handler's stream context has no vector clock")
+	}
+	if gotVc["client"] != sentVc["client"] {
+		t.Errorf("gotVc[client] = %d, want %d", gotVc["client"], sentVc["client"])
+	}
+}
+
+//fakeServerStream is a minimal grpc.ServerStream that only supports
+//Context(), enough to drive StreamServerInterceptor in a test.
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *fakeServerStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,61 @@
+package transport
+
+import (
+	"net/http"
+
+	"github.com/DistributedClocks/GoVector/govec"
+)
+
+//Middleware returns net/http middleware that merges an inbound
+//request's ClockHeader into gv (logging a "recv" event tagged with the
+//request's method and path) and attaches the resulting clock to the
+//request's context, retrievable with govec.VClockFromContext, before
+//calling next. Requests without the header are passed through
+//untouched.
+func Middleware(gv *govec.GoLog) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+
+			if header := r.Header.Get(ClockHeader); header != "" {
+				if peerVc, err := decodeClockHeader(header); err == nil {
+					vc := gv.MergeReceivedClock(r.Method+" "+r.URL.Path, r.RemoteAddr, peerVc)
+					ctx = govec.ContextWithVClock(ctx, vc)
+				}
+			}
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+//RoundTripper wraps another http.RoundTripper to attach gv's current
+//vector clock to every outbound request's ClockHeader, logging a "send"
+//event tagged with the request's method and path before each round
+//trip.
+type RoundTripper struct {
+	gv   *govec.GoLog
+	next http.RoundTripper
+}
+
+//NewRoundTripper returns a RoundTripper that instruments requests made
+//through next with gv's vector clock. next defaults to
+//http.DefaultTransport if nil.
+func NewRoundTripper(gv *govec.GoLog, next http.RoundTripper) *RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &RoundTripper{gv: gv, next: next}
+}
+
+//RoundTrip implements http.RoundTripper.
+func (rt *RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	vc := rt.gv.PrepareSendClock(req.Method + " " + req.URL.Path)
+
+	if encoded, err := encodeClockHeader(vc); err == nil {
+		req = req.Clone(req.Context())
+		req.Header.Set(ClockHeader, encoded)
+	}
+
+	return rt.next.RoundTrip(req)
+}
@@ -0,0 +1,130 @@
+package transport
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DistributedClocks/GoVector/govec"
+)
+
+func newTestGoLog(pid string) *govec.GoLog {
+	config := govec.GetDefaultConfig()
+	config.LogToFile = false
+	return govec.InitGoVector(pid, "unused", config)
+}
+
+//TestMiddlewareMergesClockHeader verifies that an inbound request
+//carrying a valid ClockHeader is merged into gv and the resulting clock
+//is attached to the handler's context.
+func TestMiddlewareMergesClockHeader(t *testing.T) {
+	serverGv := newTestGoLog("server")
+	clientGv := newTestGoLog("client")
+
+	sentVc := clientGv.PrepareSendClock("GET /widgets")
+	encoded, err := encodeClockHeader(sentVc)
+	if err != nil {
+		t.Fatalf("encodeClockHeader: %v", err)
+	}
+
+	var gotVc map[string]uint64
+	var gotOk bool
+	handler := Middleware(serverGv)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotVc, gotOk = govec.VClockFromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/widgets", nil)
+	req.Header.Set(ClockHeader, encoded)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if !gotOk {
+		t.Fatal("handler's context has no vector clock")
+	}
+	if gotVc["client"] != sentVc["client"] {
+		t.Errorf("gotVc[client] = %d, want %d", gotVc["client"], sentVc["client"])
+	}
+	if _, ok := gotVc["server"]; !ok {
+		t.Error("merged clock is missing the server's own pid")
+	}
+}
+
+//TestMiddlewarePassesThroughMissingOrMalformedHeader verifies that a
+//request without a ClockHeader, or with one that doesn't decode, is
+//passed to next unchanged rather than merging garbage into gv or
+//panicking.
+func TestMiddlewarePassesThroughMissingOrMalformedHeader(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		setHdr bool
+	}{
+		{name: "missing header", setHdr: false},
+		{name: "malformed header", header: "not-valid-base64!!!", setHdr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			serverGv := newTestGoLog("server")
+
+			var called bool
+			var gotOk bool
+			handler := Middleware(serverGv)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				_, gotOk = govec.VClockFromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/widgets", nil)
+			if test.setHdr {
+				req.Header.Set(ClockHeader, test.header)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if !called {
+				t.Fatal("next handler was never called")
+			}
+			if gotOk {
+				t.Error("expected no vector clock on the context, got one")
+			}
+		})
+	}
+}
+
+//fakeRoundTripper records the last request it saw and returns an empty
+//response.
+type fakeRoundTripper struct {
+	lastRequest *http.Request
+}
+
+func (f *fakeRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.lastRequest = req
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+}
+
+//TestRoundTripperAttachesClockHeader verifies that RoundTrip attaches
+//gv's current vector clock to the outgoing request's ClockHeader.
+func TestRoundTripperAttachesClockHeader(t *testing.T) {
+	gv := newTestGoLog("client")
+	fake := &fakeRoundTripper{}
+	rt := NewRoundTripper(gv, fake)
+
+	req := httptest.NewRequest(http.MethodGet, "/widgets", nil)
+	if _, err := rt.RoundTrip(req); err != nil {
+		t.Fatalf("RoundTrip: %v", err)
+	}
+
+	if fake.lastRequest == nil {
+		t.Fatal("underlying RoundTripper was never called")
+	}
+
+	header := fake.lastRequest.Header.Get(ClockHeader)
+	if header == "" {
+		t.Fatal("outgoing request has no ClockHeader")
+	}
+	vc, err := decodeClockHeader(header)
+	if err != nil {
+		t.Fatalf("decodeClockHeader: %v", err)
+	}
+	if _, ok := vc["client"]; !ok {
+		t.Errorf("decoded clock is missing the client's own pid: %v", vc)
+	}
+}
@@ -0,0 +1,197 @@
+package govec
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+//vmoduleRule is a single "pattern=level" entry parsed out of a VModule
+//string, e.g. "raft.go=3" or "paxos/*=2".
+type vmoduleRule struct {
+	pattern  string
+	fullPath bool
+	level    int32
+}
+
+//Verbose is the value returned by GoLog.V. It reports whether a
+//verbosity-gated call site is currently enabled, and lets callers log
+//directly against it instead of re-checking the gate themselves:
+//
+//	if gv.V(2).Enabled() {
+//	        gv.LogLocalEvent("expensive debug state: " + dump())
+//	}
+//
+//	gv.V(2).Infof("peer %s acked at %v", peer, gv.GetCurrentVC())
+type Verbose struct {
+	enabled bool
+	gv      *GoLog
+}
+
+//Enabled reports whether this verbosity gate is active for the call site
+//that produced it.
+func (v Verbose) Enabled() bool {
+	return v.enabled
+}
+
+//Info logs Message via LogLocalEvent if this verbosity gate is enabled.
+func (v Verbose) Info(Message string) bool {
+	if !v.enabled {
+		return true
+	}
+	return v.gv.LogLocalEvent(Message)
+}
+
+//Infof formats its arguments with fmt.Sprintf and logs the result via
+//LogLocalEvent if this verbosity gate is enabled.
+func (v Verbose) Infof(format string, args ...interface{}) bool {
+	if !v.enabled {
+		return true
+	}
+	return v.gv.LogLocalEvent(fmt.Sprintf(format, args...))
+}
+
+//noVModuleMatch is cached for call sites that no VModule pattern
+//matches, so V falls back to the current global verbosity for them.
+const noVModuleMatch int32 = math.MinInt32
+
+//V reports whether logging at the given verbosity level is enabled for
+//the call site invoking it. The threshold for a call site is either the
+//logger's global verbosity (set via SetVerbosity or
+//GoLogConfig.Verbosity) or, if the caller's file matches a pattern
+//configured via SetVModule/GoLogConfig.VModule, that pattern's level
+//instead.
+//
+//The caller's file is resolved once per call site (via
+//runtime.CallersFrames) and the matching pattern's level, if any, is
+//cached in a sync.Map keyed by program counter, so repeated calls from
+//the same call site only pay for reading their program counter, an
+//atomic load and a map lookup, not a fresh file lookup or glob match.
+func (gv *GoLog) V(level int32) Verbose {
+	threshold := atomic.LoadInt32(&gv.verbosity)
+
+	var pcs [1]uintptr
+	if runtime.Callers(2, pcs[:]) == 1 {
+		cached, found := gv.vmoduleCache.Load(pcs[0])
+		if !found {
+			frame, _ := runtime.CallersFrames(pcs[:]).Next()
+			cached = gv.vmoduleThreshold(frame.File, noVModuleMatch)
+			gv.vmoduleCache.Store(pcs[0], cached)
+		}
+		if override := cached.(int32); override != noVModuleMatch {
+			threshold = override
+		}
+	}
+
+	return Verbose{enabled: level <= threshold, gv: gv}
+}
+
+//vmoduleThreshold returns the verbosity threshold that applies to file,
+//falling back to defaultThreshold if no configured pattern matches it.
+func (gv *GoLog) vmoduleThreshold(file string, defaultThreshold int32) int32 {
+	gv.vmoduleMutex.RLock()
+	defer gv.vmoduleMutex.RUnlock()
+
+	base := filepath.Base(file)
+	for _, rule := range gv.vmoduleRules {
+		if rule.fullPath {
+			if matchesPathSuffix(rule.pattern, file) {
+				return rule.level
+			}
+			continue
+		}
+		if matched, _ := filepath.Match(rule.pattern, base); matched {
+			return rule.level
+		}
+	}
+	return defaultThreshold
+}
+
+//matchesPathSuffix reports whether pattern matches the trailing path
+//segments of file, one glob segment per "/"-separated component. file is
+//the absolute path runtime.Caller returns; pattern is typically a short
+//relative fragment like "paxos/*", so matching it against the whole
+//string via filepath.Match would never succeed. Instead, pattern is
+//matched only against the same number of trailing segments of file, e.g.
+//"paxos/*" against "/home/user/repo/paxos/consensus.go" matches against
+//just "paxos/consensus.go".
+func matchesPathSuffix(pattern, file string) bool {
+	patternSegments := strings.Split(pattern, "/")
+	fileSegments := strings.Split(filepath.ToSlash(file), "/")
+	if len(patternSegments) > len(fileSegments) {
+		return false
+	}
+	target := strings.Join(fileSegments[len(fileSegments)-len(patternSegments):], "/")
+	matched, _ := filepath.Match(pattern, target)
+	return matched
+}
+
+//SetVerbosity sets the default verbosity threshold used by V for call
+//sites that don't match any configured VModule pattern.
+func (gv *GoLog) SetVerbosity(level int32) {
+	atomic.StoreInt32(&gv.verbosity, level)
+}
+
+//SetVModule (re)configures the per-file/module verbosity overrides used
+//by V, from a comma-separated "pattern=level" list, e.g.
+//"raft.go=3,paxos/*=2,full/path/to/file.go=4". A pattern containing a
+//"/" is matched against as many trailing segments of the call site's
+//full path as the pattern has (see matchesPathSuffix); otherwise it is
+//matched against just the base filename. Patterns support the glob
+//syntax accepted by filepath.Match.
+func (gv *GoLog) SetVModule(pattern string) error {
+	rules, err := parseVModule(pattern)
+	if err != nil {
+		return err
+	}
+
+	gv.vmoduleMutex.Lock()
+	gv.vmoduleRules = rules
+	gv.vmoduleMutex.Unlock()
+
+	// Previously cached thresholds may now be stale. Clear entries in
+	// place rather than assigning a fresh sync.Map{}: V() may be
+	// concurrently calling Load/Store on the same field, and replacing
+	// the struct value out from under it corrupts the map's internal
+	// state.
+	gv.vmoduleCache.Range(func(key, _ interface{}) bool {
+		gv.vmoduleCache.Delete(key)
+		return true
+	})
+	return nil
+}
+
+func parseVModule(pattern string) ([]vmoduleRule, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+
+	var rules []vmoduleRule
+	for _, entry := range strings.Split(pattern, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("govec: malformed vmodule entry %q, want pattern=level", entry)
+		}
+
+		level, err := strconv.ParseInt(parts[1], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("govec: malformed vmodule level in %q: %v", entry, err)
+		}
+
+		rules = append(rules, vmoduleRule{
+			pattern:  parts[0],
+			fullPath: strings.Contains(parts[0], "/"),
+			level:    int32(level),
+		})
+	}
+	return rules, nil
+}
@@ -0,0 +1,71 @@
+package govec
+
+import (
+	"sync"
+	"testing"
+)
+
+//TestVModuleThresholdFullPathMatchesSuffix verifies that a fullPath
+//vmodule pattern (one containing "/") matches against the trailing
+//segments of a real, multi-directory absolute path as runtime.Caller
+//would report it, not against the whole string: filepath.Match requires
+//a total match, so "paxos/*" would otherwise never match
+//"/home/user/repo/paxos/consensus.go".
+func TestVModuleThresholdFullPathMatchesSuffix(t *testing.T) {
+	gv := &GoLog{pid: "test-pid"}
+	if err := gv.SetVModule("paxos/*=2"); err != nil {
+		t.Fatalf("SetVModule: %v", err)
+	}
+
+	got := gv.vmoduleThreshold("/home/user/repo/paxos/consensus.go", 0)
+	if got != 2 {
+		t.Fatalf("vmoduleThreshold = %d, want 2", got)
+	}
+
+	// A file outside paxos/ shouldn't match and must fall back to the
+	// default threshold.
+	got = gv.vmoduleThreshold("/home/user/repo/raft/consensus.go", 0)
+	if got != 0 {
+		t.Fatalf("vmoduleThreshold for non-matching path = %d, want 0 (default)", got)
+	}
+}
+
+//TestSetVModuleConcurrentWithV exercises SetVModule racing with V from
+//another goroutine. V populates gv.vmoduleCache via Load/Store while
+//SetVModule clears it; replacing the sync.Map's struct value outright
+//(instead of clearing it in place) corrupts that concurrent access and
+//crashes the process even without -race. This must run clean under
+//`go test -race`.
+func TestSetVModuleConcurrentWithV(t *testing.T) {
+	gv := &GoLog{pid: "test-pid"}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	stop := make(chan struct{})
+
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if err := gv.SetVModule("verbosity_test.go=2"); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 10000; i++ {
+			gv.V(1)
+		}
+		close(stop)
+	}()
+
+	wg.Wait()
+}